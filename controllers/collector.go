@@ -7,28 +7,70 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	cpev1 "github.com/IBM/cpe-operator/api/v1"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tidwall/gjson"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// defaultCacheRefreshInterval is the refreshInterval newResultCollector gives
+// every collector, and what Start falls back to if refreshInterval is
+// non-positive.
+const defaultCacheRefreshInterval = 30 * time.Second
+
 var (
-	cpe_result_metric_name   = "cpe_result_val"
-	cpe_result_metric_lables = []string{
+	cpe_result_metric_name    = "cpe_result_val"
+	cpe_result_histogram_name = "cpe_result_histogram"
+	cpe_result_summary_name   = "cpe_result_summary"
+	cpe_result_metric_lables  = []string{
 		"benchmark", "build", "config", "scenario", "job", "pod", "key", "attrbs",
 	}
+	// defaultHistogramBuckets is used for any ValuesWithLabels metric that
+	// requests a histogram but does not specify its own bucket boundaries.
+	defaultHistogramBuckets = prometheus.ExponentialBuckets(0.001, 2, 20)
+)
+
+// MetricKind selects how a ValuesWithLabels sample set is exported.
+type MetricKind string
+
+const (
+	GaugeMetricKind     MetricKind = "gauge"
+	HistogramMetricKind MetricKind = "histogram"
+	SummaryMetricKind   MetricKind = "summary"
 )
 
+// MetricConfig controls how a single benchmark/key combination is exported.
+// In the cluster this is sourced from a ConfigMap referenced by the
+// Benchmark CR - see ParseMetricConfigYAML, which a reconciler reads and
+// passes entry by entry to SetMetricConfig; it is plumbed in here as a
+// plain struct so the collector has no compile-time dependency on how it
+// was loaded.
+type MetricConfig struct {
+	// Type is one of GaugeMetricKind, HistogramMetricKind, SummaryMetricKind.
+	// Defaults to GaugeMetricKind when unset.
+	Type MetricKind
+	// Buckets are the histogram bucket boundaries to use when Type is
+	// HistogramMetricKind. Falls back to defaultHistogramBuckets when empty.
+	Buckets []float64
+	// Objectives are the summary quantile objectives (quantile -> max error)
+	// to use when Type is SummaryMetricKind. Falls back to prometheus
+	// DefObjectives when empty.
+	Objectives map[float64]float64
+	// DisableGauges opts out of the legacy min/max/avg gauges for this
+	// benchmark/key. Gauges are emitted by default for backward compatibility.
+	DisableGauges bool
+}
+
 type ResultType int
 
 const (
@@ -39,26 +81,35 @@ const (
 	InvalidType          ResultType = -1
 )
 
-func getResultType(vals interface{}) ResultType {
+// classifyResult inspects a gjson.Result in place, without unmarshalling it
+// into any intermediate Go value, and reports which of the result shapes a
+// benchmark may emit it is.
+func classifyResult(vals gjson.Result) ResultType {
 	switch {
-	case reflect.TypeOf(vals).Kind() == reflect.Float64:
+	case vals.Type == gjson.Number:
 		return SimpleFloatType
-	case reflect.TypeOf(vals).Kind() == reflect.Slice:
-		sliceVals, ok := vals.([]interface{})
-		if !ok || len(sliceVals) == 0 {
+	case vals.IsArray():
+		first := gjson.Result{}
+		count := 0
+		vals.ForEach(func(_, v gjson.Result) bool {
+			first = v
+			count++
+			return false
+		})
+		if count == 0 {
 			return InvalidType
 		}
-		if reflect.TypeOf(sliceVals[0]).Kind() == reflect.Float64 {
+		if first.Type == gjson.Number {
 			return SliceType
 		}
-		if mapVals, ok := sliceVals[0].(map[string]interface{}); ok {
-			if _, hasLabel := mapVals["Labels"]; !hasLabel {
+		if first.IsObject() {
+			if !first.Get("Labels").Exists() {
 				return InvalidType
 			}
-			if _, hasValue := mapVals["Value"]; hasValue {
+			if first.Get("Value").Exists() {
 				return ValueWithLabelsType
 			}
-			if _, hasValues := mapVals["Values"]; hasValues {
+			if first.Get("Values").Exists() {
 				return ValuesWithLabelsType
 			}
 		}
@@ -68,40 +119,156 @@ func getResultType(vals interface{}) ResultType {
 	}
 }
 
-type ValueWithLabels struct {
-	Labels map[string]string
-	Value  float64
+// gjsonLabels reads a "Labels" object in place into a plain label map.
+func gjsonLabels(vals gjson.Result) map[string]string {
+	labels := make(map[string]string)
+	vals.ForEach(func(k, v gjson.Result) bool {
+		labels[k.String()] = v.String()
+		return true
+	})
+	return labels
+}
+
+type ResultCollector struct {
+	client.Client
+	Log              logr.Logger
+	resultVectors    *prometheus.GaugeVec
+	resultHistograms *prometheus.HistogramVec
+	resultSummaries  *prometheus.SummaryVec
+	// configMu guards metricConfigs, guardrails, customGauges,
+	// customHistograms and customSummaries below: SetMetricConfig/
+	// SetGuardrails/gaugeVecFor/histogramVecFor/summaryVecFor run off the
+	// Start background-refresh goroutine (via UpdateFromBenchmark /
+	// observeDistributions) or a reconciler goroutine, while Collect reads
+	// them from the Prometheus scrape goroutine - without a lock that's a
+	// concurrent map read/write.
+	configMu sync.RWMutex
+	// metricConfigs is benchmarkName -> relabeled key -> MetricConfig.
+	// A missing entry falls back to the legacy gauge-only behavior.
+	metricConfigs map[string]map[string]MetricConfig
+	// customHistograms/customSummaries hold per benchmark/key vectors for
+	// configs that override the default buckets/objectives, keyed by
+	// "benchmark/key".
+	customHistograms map[string]*prometheus.HistogramVec
+	customSummaries  map[string]*prometheus.SummaryVec
+	// cache holds the parsed results of every Benchmark, refreshed by Start
+	// via UpdateFromBenchmark/RemoveBenchmark instead of on every scrape.
+	cache *resultCache
+	// refreshInterval is how often Start re-lists Benchmarks to refresh the
+	// cache.
+	refreshInterval time.Duration
+	// relabel resolves the user-defined metric mapping config, if any.
+	relabel *relabelResolver
+	// customGauges holds per benchmark/key gauge vectors for rules that
+	// promote labels to first-class Prometheus labels, keyed by
+	// "benchmark/key".
+	customGauges map[string]*prometheus.GaugeVec
+	// droppedTotal counts samples dropped by the cardinality/label-length
+	// guardrails, by benchmark and reason.
+	droppedTotal *prometheus.CounterVec
+	// seriesCount tracks how many distinct series each benchmark currently
+	// holds, for operators to watch cardinality per benchmark.
+	seriesCount *prometheus.GaugeVec
+	// defaultGuardrails is the collector-wide guardrail config, normally
+	// wired from controller flags.
+	defaultGuardrails GuardrailConfig
+	// guardrails holds per-benchmark overrides of defaultGuardrails.
+	guardrails map[string]GuardrailConfig
+	// seriesSeen tracks, for the scrape in progress, which label
+	// combinations have already been counted against a benchmark's
+	// MaxSeriesPerBenchmark guardrail.
+	seriesSeen map[string]map[string]struct{}
+}
+
+// SetRelabelConfig installs the user-defined metric mapping rules, replacing
+// any previously configured ones.
+func (c *ResultCollector) SetRelabelConfig(cfg RelabelConfig) {
+	c.relabel.SetConfig(cfg)
 }
 
-func getValueWithLabelsObjects(vals []interface{}) ([]ValueWithLabels, error) {
-	var result []ValueWithLabels
-	valsBytes, err := json.Marshal(vals)
-	if err != nil {
-		return result, err
+func (c *ResultCollector) histogramVecFor(benchmarkName, key string, cfg MetricConfig) *prometheus.HistogramVec {
+	if len(cfg.Buckets) == 0 {
+		return c.resultHistograms
+	}
+	id := benchmarkName + "/" + key
+
+	c.configMu.RLock()
+	vec, ok := c.customHistograms[id]
+	c.configMu.RUnlock()
+	if ok {
+		return vec
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	if vec, ok := c.customHistograms[id]; ok {
+		return vec
+	}
+	if c.customHistograms == nil {
+		c.customHistograms = make(map[string]*prometheus.HistogramVec)
 	}
-	err = json.Unmarshal(valsBytes, &result)
-	return result, err
+	vec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    cpe_result_histogram_name + "_" + sanitizeLabelName(key),
+		Help:    fmt.Sprintf("CPE Results distribution for %s/%s", benchmarkName, key),
+		Buckets: cfg.Buckets,
+	}, cpe_result_metric_lables)
+	c.customHistograms[id] = vec
+	return vec
 }
 
-func getValuesWithLabelsObjects(vals []interface{}) ([]ValuesWithLabels, error) {
-	var result []ValuesWithLabels
-	valsBytes, err := json.Marshal(vals)
-	if err != nil {
-		return result, err
+func (c *ResultCollector) summaryVecFor(benchmarkName, key string, cfg MetricConfig) *prometheus.SummaryVec {
+	if len(cfg.Objectives) == 0 {
+		return c.resultSummaries
+	}
+	id := benchmarkName + "/" + key
+
+	c.configMu.RLock()
+	vec, ok := c.customSummaries[id]
+	c.configMu.RUnlock()
+	if ok {
+		return vec
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	if vec, ok := c.customSummaries[id]; ok {
+		return vec
 	}
-	err = json.Unmarshal(valsBytes, &result)
-	return result, err
+	if c.customSummaries == nil {
+		c.customSummaries = make(map[string]*prometheus.SummaryVec)
+	}
+	vec = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       cpe_result_summary_name + "_" + sanitizeLabelName(key),
+		Help:       fmt.Sprintf("CPE Results quantiles for %s/%s", benchmarkName, key),
+		Objectives: cfg.Objectives,
+	}, cpe_result_metric_lables)
+	c.customSummaries[id] = vec
+	return vec
 }
 
-type ValuesWithLabels struct {
-	Labels map[string]string
-	Values []float64
+// SetMetricConfig registers how benchmarkName/key should be exported. key
+// must already be relabeled (i.e. the value produced by relabelKey).
+func (c *ResultCollector) SetMetricConfig(benchmarkName, key string, cfg MetricConfig) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	if c.metricConfigs == nil {
+		c.metricConfigs = make(map[string]map[string]MetricConfig)
+	}
+	if c.metricConfigs[benchmarkName] == nil {
+		c.metricConfigs[benchmarkName] = make(map[string]MetricConfig)
+	}
+	c.metricConfigs[benchmarkName][key] = cfg
 }
 
-type ResultCollector struct {
-	client.Client
-	Log           logr.Logger
-	resultVectors *prometheus.GaugeVec
+func (c *ResultCollector) metricConfigFor(benchmarkName, key string) MetricConfig {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
+	if keyed, ok := c.metricConfigs[benchmarkName]; ok {
+		if cfg, ok := keyed[key]; ok {
+			return cfg
+		}
+	}
+	return MetricConfig{Type: GaugeMetricKind}
 }
 
 func (c *ResultCollector) relabelKey(key string) string {
@@ -132,41 +299,202 @@ func (c *ResultCollector) labelMapToStr(labelMap map[string]string) string {
 	return strings.ToLower(str)
 }
 
-func NewCollector(client client.Client, logger logr.Logger) {
-	collector := &ResultCollector{
-		Client: client,
-		Log:    logger,
+// splitLabels partitions labelMap into the subset whose keys are listed in
+// promoteLabels (returned for use as first-class Prometheus label values)
+// and the remainder, folded into the legacy attrbs string as before. With
+// no promoteLabels this degrades to the original labelMapToStr behavior.
+func (c *ResultCollector) splitLabels(labelMap map[string]string, promoteLabels []string) (promoted map[string]string, attrbs string) {
+	if len(promoteLabels) == 0 {
+		return nil, c.labelMapToStr(labelMap)
+	}
+	promoteSet := make(map[string]bool, len(promoteLabels))
+	for _, k := range promoteLabels {
+		promoteSet[k] = true
+	}
+	promoted = make(map[string]string, len(promoteLabels))
+	remaining := make(map[string]string, len(labelMap))
+	for k, v := range labelMap {
+		if promoteSet[k] {
+			promoted[sanitizeLabelName(k)] = v
+		} else {
+			remaining[k] = v
+		}
+	}
+	return promoted, c.labelMapToStr(remaining)
+}
+
+// labelNamesWithPromoted returns cpe_result_metric_lables extended with the
+// given promoted label names, sorted for a stable, deterministic vector
+// identity.
+func labelNamesWithPromoted(promoteLabels []string) []string {
+	if len(promoteLabels) == 0 {
+		return cpe_result_metric_lables
+	}
+	names := append([]string{}, cpe_result_metric_lables...)
+	extra := make([]string, 0, len(promoteLabels))
+	for _, l := range promoteLabels {
+		extra = append(extra, sanitizeLabelName(l))
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
+
+// gaugeVecFor returns the gauge vector that should receive samples for
+// benchmarkName/key. Keys with no promoted labels share the single
+// cpe_result_val vector as before; keys that promote labels get their own
+// vector carrying those extra label names.
+func (c *ResultCollector) gaugeVecFor(benchmarkName, key string, promoteLabels []string) *prometheus.GaugeVec {
+	if len(promoteLabels) == 0 {
+		return c.resultVectors
+	}
+	id := benchmarkName + "/" + key
+
+	c.configMu.RLock()
+	vec, ok := c.customGauges[id]
+	c.configMu.RUnlock()
+	if ok {
+		return vec
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	if vec, ok := c.customGauges[id]; ok {
+		return vec
+	}
+	if c.customGauges == nil {
+		c.customGauges = make(map[string]*prometheus.GaugeVec)
+	}
+	vec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: cpe_result_metric_name + "_" + sanitizeLabelName(key),
+		Help: fmt.Sprintf("CPE Results with parsed key and promoted labels for %s/%s", benchmarkName, key),
+	}, labelNamesWithPromoted(promoteLabels))
+	c.customGauges[id] = vec
+	return vec
+}
+
+// newResultCollector builds a ResultCollector with a fresh set of vectors,
+// without registering it anywhere. NewCollector registers it for scraping;
+// NewPusher uses one as a private sample sink for the push path instead.
+func newResultCollector(client client.Client, logger logr.Logger) *ResultCollector {
+	return &ResultCollector{
+		Client:          client,
+		Log:             logger,
+		refreshInterval: defaultCacheRefreshInterval,
 		resultVectors: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: cpe_result_metric_name,
 			Help: "CPE Results with parsed key and index if applicable",
 		}, cpe_result_metric_lables),
+		resultHistograms: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    cpe_result_histogram_name,
+			Help:    "CPE Results distribution for keys configured as histogram",
+			Buckets: defaultHistogramBuckets,
+		}, cpe_result_metric_lables),
+		resultSummaries: prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Name:       cpe_result_summary_name,
+			Help:       "CPE Results quantiles for keys configured as summary",
+			Objectives: prometheus.DefObjectives,
+		}, cpe_result_metric_lables),
+		droppedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: cpe_result_dropped_total_name,
+			Help: "Number of CPE result samples dropped by the cardinality/label-length guardrails",
+		}, []string{"benchmark", "reason"}),
+		seriesCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: cpe_result_series_count_name,
+			Help: "Number of distinct CPE result series currently held per benchmark",
+		}, []string{"benchmark"}),
+		cache:   newResultCache(),
+		relabel: newRelabelResolver(logger),
 	}
+}
+
+// NewCollector registers a ResultCollector for scraping and returns it so
+// the caller can additionally add it to the manager with mgr.Add(collector)
+// - ResultCollector implements manager.Runnable via Start, which is what
+// actually keeps the result cache populated. Without that, Collect has
+// nothing to read and every benchmark reports zero series.
+func NewCollector(client client.Client, logger logr.Logger) *ResultCollector {
+	collector := newResultCollector(client, logger)
 	// register prometheus
 	metrics.Registry.MustRegister(collector)
+	return collector
+}
+
+// Start implements sigs.k8s.io/controller-runtime/pkg/manager.Runnable. It
+// refreshes the result cache immediately and then every refreshInterval
+// until ctx is cancelled, so that Collect (the Prometheus scrape path) never
+// itself lists Benchmarks or unmarshals their results.
+func (c *ResultCollector) Start(ctx context.Context) error {
+	c.refreshCache(ctx)
+	interval := c.refreshInterval
+	if interval <= 0 {
+		interval = defaultCacheRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.refreshCache(ctx)
+		}
+	}
+}
+
+// refreshCache lists every Benchmark, feeds each into UpdateFromBenchmark,
+// and evicts any cached Benchmark that no longer exists via RemoveBenchmark.
+func (c *ResultCollector) refreshCache(ctx context.Context) {
+	benchmarks := &cpev1.BenchmarkList{}
+	if err := c.Client.List(ctx, benchmarks, &client.ListOptions{Namespace: metav1.NamespaceAll}); err != nil {
+		c.Log.Info(fmt.Sprintf("Failed to list Benchmarks for cache refresh: %v", err))
+		return
+	}
+	seen := make(map[string]struct{}, len(benchmarks.Items))
+	for i := range benchmarks.Items {
+		benchmark := &benchmarks.Items[i]
+		seen[benchmark.Namespace+"/"+benchmark.Name] = struct{}{}
+		c.UpdateFromBenchmark(benchmark)
+	}
+	for _, benchmarkKey := range c.cache.BenchmarkKeys() {
+		if _, ok := seen[benchmarkKey]; !ok {
+			c.cache.RemoveBenchmark(benchmarkKey)
+		}
+	}
 }
 
 // Describe implements the prometheus.Collector interface
 func (c *ResultCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.resultVectors.Describe(ch)
+	c.resultHistograms.Describe(ch)
+	c.resultSummaries.Describe(ch)
+	c.droppedTotal.Describe(ch)
+	c.seriesCount.Describe(ch)
 }
 
-func (c *ResultCollector) getStat(vals []float64) (minVal, maxVal, avgVal float64) {
-	if len(vals) == 0 {
-		// no values
-		return -1, -1, -1
-	}
-	minVal = vals[0]
-	maxVal = vals[0]
-	var sumVal float64 = 0
-	for _, val := range vals {
-		if val > maxVal {
+// getStat streams vals (a gjson "Values" array) in a single pass, never
+// materializing it into a []float64, since ValuesWithLabels arrays are the
+// main source of large benchmark payloads.
+func (c *ResultCollector) getStat(vals gjson.Result) (minVal, maxVal, avgVal float64) {
+	var sumVal float64
+	count := 0
+	vals.ForEach(func(_, v gjson.Result) bool {
+		val := v.Float()
+		if count == 0 {
+			minVal, maxVal = val, val
+		} else if val > maxVal {
 			maxVal = val
 		} else if val < minVal {
 			minVal = val
 		}
 		sumVal += val
+		count++
+		return true
+	})
+	if count == 0 {
+		// no values
+		return -1, -1, -1
 	}
-	avgVal = sumVal / float64(len(vals))
+	avgVal = sumVal / float64(count)
 	return
 }
 
@@ -181,86 +509,279 @@ func (c *ResultCollector) getCommonLabels(benchmarkName, build, configID, scenar
 	return labels
 }
 
-func (c *ResultCollector) updateGaugeVec(benchmarkName, build, configID, scenarioID, jobName, podName string, values map[string]interface{}) {
-	for key, vals := range values {
+// updateGaugeVec walks rawResult (one benchmark item's raw JSON, as produced
+// by the benchmark pod) with gjson, top-level key by top-level key, without
+// ever unmarshalling it into a map[string]interface{} or []interface{} -
+// benchmark result payloads can carry large Values arrays, and this used to
+// be the hot path that paid for a full json.Unmarshal on every scrape. It
+// only ever sets gauges, never observes histograms/summaries, so it is safe
+// to call on every Collect even though the underlying rawResult is
+// unchanged between scrapes - see observeDistributions for the
+// once-per-sample counterpart.
+func (c *ResultCollector) updateGaugeVec(benchmarkName, build, configID, scenarioID, jobName, podName, rawResult string) {
+	gjson.Parse(rawResult).ForEach(func(keyResult, vals gjson.Result) bool {
+		key := keyResult.String()
+		rule := c.relabel.Resolve(benchmarkName, key)
 		relabeledKey := c.relabelKey(key)
-		switch getResultType(vals) {
+		valueMultiplier := 1.0
+		var promoteLabels []string
+		if rule != nil {
+			if rule.TargetKey != "" {
+				relabeledKey = rule.TargetKey
+			}
+			if rule.ValueMultiplier != 0 {
+				valueMultiplier = rule.ValueMultiplier
+			}
+			promoteLabels = rule.PromoteLabels
+		}
+
+		switch classifyResult(vals) {
 		case SimpleFloatType:
 			labels := c.getCommonLabels(benchmarkName, build, configID, scenarioID, jobName, podName)
 			labels["key"] = relabeledKey
 			labels["attrbs"] = ""
-			c.resultVectors.With(labels).Set(vals.(float64))
+			if c.guardrailsPass(benchmarkName, labels) {
+				c.resultVectors.With(labels).Set(vals.Float() * valueMultiplier)
+			}
 		case SliceType:
-			for index, val := range vals.([]interface{}) {
+			index := 0
+			vals.ForEach(func(_, val gjson.Result) bool {
 				labels := c.getCommonLabels(benchmarkName, build, configID, scenarioID, jobName, podName)
 				labels["key"] = relabeledKey
 				labels["attrbs"] = fmt.Sprintf("%d", index)
-				c.resultVectors.With(labels).Set(val.(float64))
-			}
+				if c.guardrailsPass(benchmarkName, labels) {
+					c.resultVectors.With(labels).Set(val.Float() * valueMultiplier)
+				}
+				index++
+				return true
+			})
 		case ValueWithLabelsType:
-			if valueWithLabelsArr, err := getValueWithLabelsObjects(vals.([]interface{})); err == nil {
-				for _, valueWithLabels := range valueWithLabelsArr {
-					labels := c.getCommonLabels(benchmarkName, build, configID, scenarioID, jobName, podName)
-					labels["key"] = relabeledKey
-					labels["attrbs"] = c.labelMapToStr(valueWithLabels.Labels)
-					c.resultVectors.With(labels).Set(valueWithLabels.Value)
+			vals.ForEach(func(_, item gjson.Result) bool {
+				promoted, attrbs := c.splitLabels(gjsonLabels(item.Get("Labels")), promoteLabels)
+				labels := c.getCommonLabels(benchmarkName, build, configID, scenarioID, jobName, podName)
+				labels["key"] = relabeledKey
+				labels["attrbs"] = attrbs
+				for k, v := range promoted {
+					labels[k] = v
 				}
-			} else {
-				c.Log.Info(fmt.Sprintf("Failed to process result: %v", err))
-			}
+				if c.guardrailsPass(benchmarkName, labels) {
+					c.gaugeVecFor(benchmarkName, relabeledKey, promoteLabels).With(labels).Set(item.Get("Value").Float() * valueMultiplier)
+				}
+				return true
+			})
 		case ValuesWithLabelsType:
-			if valuesWithLabelsArr, err := getValuesWithLabelsObjects(vals.([]interface{})); err == nil {
-				for _, valuesWithLabels := range valuesWithLabelsArr {
-					minVal, maxVal, avgVal := c.getStat(valuesWithLabels.Values)
+			cfg := c.metricConfigFor(benchmarkName, relabeledKey)
+			vals.ForEach(func(_, item gjson.Result) bool {
+				promoted, attrbs := c.splitLabels(gjsonLabels(item.Get("Labels")), promoteLabels)
+				valuesResult := item.Get("Values")
+
+				if !cfg.DisableGauges {
+					minVal, maxVal, avgVal := c.getStat(valuesResult)
 					minLabels := c.getCommonLabels(benchmarkName, build, configID, scenarioID, jobName, podName)
 					maxLables := c.getCommonLabels(benchmarkName, build, configID, scenarioID, jobName, podName)
 					avgLables := c.getCommonLabels(benchmarkName, build, configID, scenarioID, jobName, podName)
 					minLabels["key"] = relabeledKey
 					maxLables["key"] = relabeledKey
 					avgLables["key"] = relabeledKey
-					minLabels["attrbs"] = c.labelMapToStr(valuesWithLabels.Labels) + "_min"
-					maxLables["attrbs"] = c.labelMapToStr(valuesWithLabels.Labels) + "_max"
-					avgLables["attrbs"] = c.labelMapToStr(valuesWithLabels.Labels) + "_avg"
-					c.resultVectors.With(minLabels).Set(minVal)
-					c.resultVectors.With(maxLables).Set(maxVal)
-					c.resultVectors.With(avgLables).Set(avgVal)
+					minLabels["attrbs"] = attrbs + "_min"
+					maxLables["attrbs"] = attrbs + "_max"
+					avgLables["attrbs"] = attrbs + "_avg"
+					for k, v := range promoted {
+						minLabels[k] = v
+						maxLables[k] = v
+						avgLables[k] = v
+					}
+					gaugeVec := c.gaugeVecFor(benchmarkName, relabeledKey, promoteLabels)
+					if c.guardrailsPass(benchmarkName, minLabels) {
+						gaugeVec.With(minLabels).Set(minVal * valueMultiplier)
+					}
+					if c.guardrailsPass(benchmarkName, maxLables) {
+						gaugeVec.With(maxLables).Set(maxVal * valueMultiplier)
+					}
+					if c.guardrailsPass(benchmarkName, avgLables) {
+						gaugeVec.With(avgLables).Set(avgVal * valueMultiplier)
+					}
 				}
-			} else {
-				c.Log.Info(fmt.Sprintf("Failed to process result: %v", err))
-			}
+				return true
+			})
 		case InvalidType:
-			c.Log.Info(fmt.Sprintf("Wrong type: %v", vals))
+			c.Log.Info(fmt.Sprintf("Wrong type for %s/%s key %s", benchmarkName, build, key))
+		}
+		return true
+	})
+}
+
+// observeDistributions walks rawResult exactly like updateGaugeVec but feeds
+// only the ValuesWithLabelsType samples configured as HistogramMetricKind or
+// SummaryMetricKind into their vectors. Unlike a gauge Set, a
+// HistogramVec/SummaryVec Observe is additive, so this must run exactly
+// once per sample - it is called from UpdateFromBenchmark (and PushItem)
+// when a cache entry is first written or changes, never from Collect, which
+// would otherwise re-observe the same unchanged samples on every scrape and
+// make bucket counts and quantiles a function of scrape frequency instead of
+// the benchmark's actual distribution.
+func (c *ResultCollector) observeDistributions(benchmarkName, build, configID, scenarioID, jobName, podName, rawResult string) {
+	guardrails := c.guardrailFor(benchmarkName)
+	gjson.Parse(rawResult).ForEach(func(keyResult, vals gjson.Result) bool {
+		key := keyResult.String()
+		if classifyResult(vals) != ValuesWithLabelsType {
+			return true
 		}
+		rule := c.relabel.Resolve(benchmarkName, key)
+		relabeledKey := c.relabelKey(key)
+		valueMultiplier := 1.0
+		var promoteLabels []string
+		if rule != nil {
+			if rule.TargetKey != "" {
+				relabeledKey = rule.TargetKey
+			}
+			if rule.ValueMultiplier != 0 {
+				valueMultiplier = rule.ValueMultiplier
+			}
+			promoteLabels = rule.PromoteLabels
+		}
+		cfg := c.metricConfigFor(benchmarkName, relabeledKey)
+		if cfg.Type != HistogramMetricKind && cfg.Type != SummaryMetricKind {
+			return true
+		}
+		vals.ForEach(func(_, item gjson.Result) bool {
+			_, attrbs := c.splitLabels(gjsonLabels(item.Get("Labels")), promoteLabels)
+			labels := c.getCommonLabels(benchmarkName, build, configID, scenarioID, jobName, podName)
+			labels["key"] = relabeledKey
+			labels["attrbs"] = attrbs
+			if !c.labelValuesWithinLimit(benchmarkName, labels, guardrails) {
+				return true
+			}
+			valuesResult := item.Get("Values")
+			switch cfg.Type {
+			case HistogramMetricKind:
+				histogram := c.histogramVecFor(benchmarkName, relabeledKey, cfg).With(labels)
+				valuesResult.ForEach(func(_, v gjson.Result) bool {
+					histogram.Observe(v.Float() * valueMultiplier)
+					return true
+				})
+			case SummaryMetricKind:
+				summary := c.summaryVecFor(benchmarkName, relabeledKey, cfg).With(labels)
+				valuesResult.ForEach(func(_, v gjson.Result) bool {
+					summary.Observe(v.Float() * valueMultiplier)
+					return true
+				})
+			}
+			return true
+		})
+		return true
+	})
+}
+
+// benchmarkNameFromCacheKey extracts the Benchmark name from a
+// "namespace/name" cache key.
+func benchmarkNameFromCacheKey(benchmarkKey string) string {
+	if idx := strings.IndexByte(benchmarkKey, '/'); idx >= 0 {
+		return benchmarkKey[idx+1:]
+	}
+	return benchmarkKey
+}
+
+// UpdateFromBenchmark parses benchmark.Status.Results and refreshes the
+// result cache entries for this Benchmark. refreshCache calls this for every
+// Benchmark on each periodic relist; a reconciler may also call it directly
+// right after writing a new status for an immediate refresh, instead of
+// waiting for the next tick. Either way this never runs from Collect, so a
+// Prometheus scrape never blocks on the API server or on JSON unmarshalling
+// and never races with a status write. Any item whose result is new or has
+// changed is also fed into observeDistributions here, since that must
+// happen exactly once per sample rather than on every later scrape.
+func (c *ResultCollector) UpdateFromBenchmark(benchmark *cpev1.Benchmark) {
+	benchmarkKey := benchmark.Namespace + "/" + benchmark.Name
+	for _, result := range benchmark.Status.Results {
+		for _, item := range result.Items {
+			if !gjson.Valid(item.Result) {
+				c.Log.Info(fmt.Sprintf("Cannot parse values of %s from respone: %s", benchmark.Name, item.Result))
+				continue
+			}
+			key := resultCacheKey{
+				benchmarkKey: benchmarkKey,
+				buildID:      result.BuildID,
+				configID:     result.ConfigurationID,
+				iterationID:  result.IterationID,
+				jobName:      item.JobName,
+				podName:      item.PodName,
+			}
+			if c.cache.Put(key, item.Result) {
+				c.observeDistributions(benchmark.Name, result.BuildID, result.ConfigurationID, result.IterationID, item.JobName, item.PodName, item.Result)
+			}
+		}
+	}
+}
+
+// RemoveBenchmark evicts every cached result for the given Benchmark.
+// refreshCache calls this once a Benchmark drops out of a List; a
+// reconciler's delete handler may also call it directly for an immediate
+// eviction instead of waiting for the next refresh.
+func (c *ResultCollector) RemoveBenchmark(namespace, name string) {
+	c.cache.RemoveBenchmark(namespace + "/" + name)
+}
+
+// customGaugeSnapshot, customHistogramSnapshot and customSummarySnapshot
+// copy the current custom-vector maps under configMu's read lock, so the
+// caller can Reset/Collect each vector afterwards without holding the lock
+// for the duration (Collect can block on a channel send) while still being
+// safe against gaugeVecFor/histogramVecFor/summaryVecFor inserting a new
+// entry concurrently.
+func (c *ResultCollector) customGaugeSnapshot() []*prometheus.GaugeVec {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
+	vecs := make([]*prometheus.GaugeVec, 0, len(c.customGauges))
+	for _, vec := range c.customGauges {
+		vecs = append(vecs, vec)
 	}
+	return vecs
+}
+
+func (c *ResultCollector) customHistogramSnapshot() []*prometheus.HistogramVec {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
+	vecs := make([]*prometheus.HistogramVec, 0, len(c.customHistograms))
+	for _, vec := range c.customHistograms {
+		vecs = append(vecs, vec)
+	}
+	return vecs
+}
+
+func (c *ResultCollector) customSummarySnapshot() []*prometheus.SummaryVec {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
+	vecs := make([]*prometheus.SummaryVec, 0, len(c.customSummaries))
+	for _, vec := range c.customSummaries {
+		vecs = append(vecs, vec)
+	}
+	return vecs
 }
 
 // Collect implements the prometheus.Collector interface
 // "benchmark", "build", "configID", "scenarioID", "job", "pod", "key", "index"
 func (c *ResultCollector) Collect(ch chan<- prometheus.Metric) {
-	benchmarks := &cpev1.BenchmarkList{}
-	c.Client.List(context.TODO(), benchmarks, &client.ListOptions{
-		Namespace: metav1.NamespaceAll,
-	})
 	c.resultVectors.Reset()
-	for _, benchmark := range benchmarks.Items {
-		benchmarkName := benchmark.Name
-		c.Log.Info(fmt.Sprintf("Collecting %d result of %s/%s", len(benchmark.Status.Results), benchmark.Namespace, benchmarkName))
-		for _, result := range benchmark.Status.Results {
-			build := result.BuildID
-			configID := result.ConfigurationID
-			scenarioID := result.IterationID
-			for _, item := range result.Items {
-				values := make(map[string]interface{})
-				err := json.Unmarshal([]byte(item.Result), &values)
-				if err != nil {
-					c.Log.Info(fmt.Sprintf("Cannot parse values of %s from respone: %s: %v", benchmarkName, item.Result, err))
-					continue
-				}
-				jobName := item.JobName
-				podName := item.PodName
-				c.updateGaugeVec(benchmarkName, build, configID, scenarioID, jobName, podName, values)
-			}
-		}
+	c.resetGuardrailState()
+	for _, vec := range c.customGaugeSnapshot() {
+		vec.Reset()
+	}
+	for key, rawResult := range c.cache.Snapshot() {
+		benchmarkName := benchmarkNameFromCacheKey(key.benchmarkKey)
+		c.updateGaugeVec(benchmarkName, key.buildID, key.configID, key.iterationID, key.jobName, key.podName, rawResult)
 	}
 	c.resultVectors.Collect(ch)
+	c.resultHistograms.Collect(ch)
+	c.resultSummaries.Collect(ch)
+	c.droppedTotal.Collect(ch)
+	c.seriesCount.Collect(ch)
+	for _, vec := range c.customGaugeSnapshot() {
+		vec.Collect(ch)
+	}
+	for _, vec := range c.customHistogramSnapshot() {
+		vec.Collect(ch)
+	}
+	for _, vec := range c.customSummarySnapshot() {
+		vec.Collect(ch)
+	}
 }
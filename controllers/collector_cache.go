@@ -0,0 +1,95 @@
+/*
+ * Copyright 2022- IBM Inc. All rights reserved
+ * SPDX-License-Identifier: Apache2.0
+ */
+
+package controllers
+
+import "sync"
+
+// resultCacheKey uniquely identifies one parsed benchmark result item.
+type resultCacheKey struct {
+	benchmarkKey string // namespace/name
+	buildID      string
+	configID     string
+	iterationID  string
+	jobName      string
+	podName      string
+}
+
+// resultCache holds raw benchmark result JSON. It is populated by
+// ResultCollector.Start's periodic refresh (or by a reconciler calling
+// UpdateFromBenchmark directly) and read by ResultCollector.Collect on every
+// Prometheus scrape, so that scraping never blocks on the API server, never
+// unmarshals results into Go values, and never races with the reconciler
+// writing to Benchmark.Status.Results. The value
+// is kept as the raw string rather than a parsed map so updateGaugeVec can
+// walk it with gjson without an intermediate unmarshal.
+type resultCache struct {
+	mu sync.RWMutex
+	// entries holds the raw result JSON for every item seen so far.
+	entries map[resultCacheKey]string
+	// byBenchmark indexes entries by benchmark so RemoveBenchmark can evict
+	// everything for a deleted Benchmark without scanning the whole cache.
+	byBenchmark map[string]map[resultCacheKey]struct{}
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{
+		entries:     make(map[resultCacheKey]string),
+		byBenchmark: make(map[string]map[resultCacheKey]struct{}),
+	}
+}
+
+// Put stores or replaces the raw result JSON for key and reports whether the
+// value is new or changed, so a caller can react exactly once to genuinely
+// new data (e.g. feed it into a histogram/summary) instead of on every
+// later Collect that re-reads the same unchanged entry.
+func (rc *resultCache) Put(key resultCacheKey, rawResult string) (changed bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if existing, ok := rc.entries[key]; ok && existing == rawResult {
+		return false
+	}
+	rc.entries[key] = rawResult
+	if rc.byBenchmark[key.benchmarkKey] == nil {
+		rc.byBenchmark[key.benchmarkKey] = make(map[resultCacheKey]struct{})
+	}
+	rc.byBenchmark[key.benchmarkKey][key] = struct{}{}
+	return true
+}
+
+// RemoveBenchmark evicts every cached entry for benchmarkKey (namespace/name).
+func (rc *resultCache) RemoveBenchmark(benchmarkKey string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for key := range rc.byBenchmark[benchmarkKey] {
+		delete(rc.entries, key)
+	}
+	delete(rc.byBenchmark, benchmarkKey)
+}
+
+// Snapshot returns a shallow copy of the cache contents, safe to range over
+// without holding the cache lock for the duration of a scrape.
+func (rc *resultCache) Snapshot() map[resultCacheKey]string {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	out := make(map[resultCacheKey]string, len(rc.entries))
+	for k, v := range rc.entries {
+		out[k] = v
+	}
+	return out
+}
+
+// BenchmarkKeys returns the "namespace/name" key of every Benchmark the
+// cache currently holds an entry for, so a caller refreshing the cache from
+// a List can tell which benchmarks are no longer present and evict them.
+func (rc *resultCache) BenchmarkKeys() []string {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	keys := make([]string, 0, len(rc.byBenchmark))
+	for k := range rc.byBenchmark {
+		keys = append(keys, k)
+	}
+	return keys
+}
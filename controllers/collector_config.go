@@ -0,0 +1,135 @@
+/*
+ * Copyright 2022- IBM Inc. All rights reserved
+ * SPDX-License-Identifier: Apache2.0
+ */
+
+package controllers
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// metricConfigEntryYAML is the on-disk shape of a single benchmark/key's
+// MetricConfig, as stored in the "metrics.yaml" key of the ConfigMap
+// referenced by a Benchmark's spec.metricConfigMapRef.
+type metricConfigEntryYAML struct {
+	Benchmark string `json:"benchmark"`
+	// Key must already be relabeled (the value produced by relabelKey),
+	// matching what SetMetricConfig expects.
+	Key string `json:"key"`
+	// MetricConfig is embedded anonymously so its fields (type, buckets,
+	// disableGauges) are promoted straight into this entry's JSON object
+	// instead of nesting under a "metricconfig" key. objectives cannot be
+	// given here: MetricConfig.Objectives is keyed by float64, which the JSON
+	// decoder does not accept as a map key, so an entry carrying objectives
+	// makes ParseMetricConfigYAML return an error.
+	MetricConfig
+}
+
+type metricConfigFileYAML struct {
+	Metrics []metricConfigEntryYAML `json:"metrics"`
+}
+
+// ParseMetricConfigYAML parses a "metrics.yaml"-shaped ConfigMap data entry
+// into the benchmarkName -> key -> MetricConfig map expected by
+// ResultCollector.SetMetricConfig, e.g.:
+//
+//	metrics:
+//	  - benchmark: fio
+//	    key: fio_latency_us
+//	    type: histogram
+//	    buckets: [100, 500, 1000, 5000]
+func ParseMetricConfigYAML(data []byte) (map[string]map[string]MetricConfig, error) {
+	var parsed metricConfigFileYAML
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing metric config: %w", err)
+	}
+	out := make(map[string]map[string]MetricConfig, len(parsed.Metrics))
+	for _, entry := range parsed.Metrics {
+		if out[entry.Benchmark] == nil {
+			out[entry.Benchmark] = make(map[string]MetricConfig)
+		}
+		out[entry.Benchmark][entry.Key] = entry.MetricConfig
+	}
+	return out, nil
+}
+
+// relabelConfigFileYAML is the on-disk shape of RelabelConfig, as stored in
+// the ConfigMap referenced by a Benchmark's spec.relabelConfigMapRef (or a
+// cluster-scoped mapping CR).
+type relabelConfigFileYAML struct {
+	Rules []RelabelRule `json:"rules"`
+}
+
+// ParseRelabelConfigYAML parses a ConfigMap data entry into a RelabelConfig,
+// e.g.:
+//
+//	rules:
+//	  - match: "fio/bw *"
+//	    targetKey: fio_bandwidth_bytes_per_second
+//	    valueMultiplier: 1048576
+//	  - match: "^sysbench/.*lat.*$"
+//	    matchIsRegex: true
+//	    promoteLabels: ["percentile"]
+//
+// Pass the result to ResultCollector.SetRelabelConfig, which is what
+// actually compiles and validates any regex rules.
+func ParseRelabelConfigYAML(data []byte) (RelabelConfig, error) {
+	var parsed relabelConfigFileYAML
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return RelabelConfig{}, fmt.Errorf("parsing relabel config: %w", err)
+	}
+	return RelabelConfig{Rules: parsed.Rules}, nil
+}
+
+// PusherFlags holds the command-line configuration for a ResultPusher,
+// registered with RegisterPusherFlags.
+type PusherFlags struct {
+	GatewayURL     string
+	AuthUsername   string
+	AuthPassword   string
+	Interval       time.Duration
+	RemoteWriteURL string
+}
+
+// RegisterPusherFlags registers the --push-gateway-url/--remote-write-url
+// flags referenced by ResultPusher's doc comment onto fs (typically
+// flag.CommandLine) and returns the struct they populate. Call NewPusher
+// on the result once fs has been parsed.
+func RegisterPusherFlags(fs *flag.FlagSet) *PusherFlags {
+	pf := &PusherFlags{}
+	fs.StringVar(&pf.GatewayURL, "push-gateway-url", "", "Prometheus Pushgateway URL to push short-lived benchmark results to. Pushing is disabled if empty.")
+	fs.StringVar(&pf.AuthUsername, "push-gateway-username", "", "Basic auth username for the Pushgateway. Leave empty to disable basic auth.")
+	fs.StringVar(&pf.AuthPassword, "push-gateway-password", "", "Basic auth password for the Pushgateway.")
+	fs.DurationVar(&pf.Interval, "push-interval", 0, "Minimum spacing between two pushes of the same benchmark/build/config/scenario/job/pod group. Zero pushes every time.")
+	fs.StringVar(&pf.RemoteWriteURL, "remote-write-url", "", "Prometheus Remote Write endpoint to additionally ship pushed results to. Remote Write is disabled if empty.")
+	return pf
+}
+
+// NewPusher builds the ResultPusher described by pf.
+func (pf *PusherFlags) NewPusher(c client.Client, logger logr.Logger) *ResultPusher {
+	pusher := NewPusher(c, logger, pf.GatewayURL, PushAuth{Username: pf.AuthUsername, Password: pf.AuthPassword}, pf.Interval)
+	if pf.RemoteWriteURL != "" {
+		pusher = pusher.WithRemoteWrite(pf.RemoteWriteURL)
+	}
+	return pusher
+}
+
+// RegisterGuardrailFlags registers the controller-wide cardinality
+// guardrail flags onto fs (typically flag.CommandLine) and returns the
+// GuardrailConfig they populate. Pass the result to
+// ResultCollector.SetDefaultGuardrails once fs has been parsed; a
+// Benchmark's own CR spec can still override it per-benchmark via
+// SetGuardrails.
+func RegisterGuardrailFlags(fs *flag.FlagSet) *GuardrailConfig {
+	cfg := &GuardrailConfig{}
+	fs.IntVar(&cfg.MaxSeriesPerBenchmark, "max-series-per-benchmark", 0, "Maximum distinct label combinations a single benchmark may emit per scrape before samples are dropped. Zero uses the built-in default.")
+	fs.IntVar(&cfg.MaxLabelValueLength, "max-label-value-length", 0, "Maximum length of any label value produced for a benchmark before samples are dropped. Zero uses the built-in default.")
+	return cfg
+}
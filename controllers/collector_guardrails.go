@@ -0,0 +1,190 @@
+/*
+ * Copyright 2022- IBM Inc. All rights reserved
+ * SPDX-License-Identifier: Apache2.0
+ */
+
+package controllers
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultMaxSeriesPerBenchmark = 2000
+	defaultMaxLabelValueLength   = 256
+)
+
+var (
+	cpe_result_dropped_total_name = "cpe_result_dropped_total"
+	cpe_result_series_count_name  = "cpe_result_series_count"
+
+	labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// sanitizeLabelName makes name usable as a Prometheus label name or metric
+// name component, per the [a-zA-Z_][a-zA-Z0-9_]* rule: disallowed
+// characters become "_", and a name that would otherwise start with a
+// digit gets an underscore prefix.
+func sanitizeLabelName(name string) string {
+	if labelNameRE.MatchString(name) {
+		return name
+	}
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	sanitized := b.String()
+	if sanitized == "" || (sanitized[0] >= '0' && sanitized[0] <= '9') {
+		sanitized = "_" + sanitized
+	}
+	return sanitized
+}
+
+// GuardrailConfig bounds how much cardinality a single Benchmark may add to
+// the collector. In the cluster the cluster-wide default is sourced from
+// controller flags - see RegisterGuardrailFlags - with an optional
+// per-Benchmark override on the CR passed to SetGuardrails.
+type GuardrailConfig struct {
+	// MaxSeriesPerBenchmark caps the number of distinct label combinations
+	// a single benchmark may emit per scrape. Additional series are
+	// dropped and counted in cpe_result_dropped_total. Zero means "use the
+	// collector-wide default".
+	MaxSeriesPerBenchmark int
+	// MaxLabelValueLength caps the length of any label value produced for
+	// a benchmark (most relevantly the attrbs label). Samples whose labels
+	// exceed it are dropped rather than truncated, since a truncated
+	// attrbs value can silently collide with an unrelated one. Zero means
+	// "use the collector-wide default".
+	MaxLabelValueLength int
+}
+
+func (g GuardrailConfig) maxSeries() int {
+	if g.MaxSeriesPerBenchmark > 0 {
+		return g.MaxSeriesPerBenchmark
+	}
+	return defaultMaxSeriesPerBenchmark
+}
+
+func (g GuardrailConfig) maxLabelValueLength() int {
+	if g.MaxLabelValueLength > 0 {
+		return g.MaxLabelValueLength
+	}
+	return defaultMaxLabelValueLength
+}
+
+// SetDefaultGuardrails sets the collector-wide guardrails, normally wired
+// from controller flags (e.g. --max-series-per-benchmark).
+func (c *ResultCollector) SetDefaultGuardrails(cfg GuardrailConfig) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	c.defaultGuardrails = cfg
+}
+
+// SetGuardrails overrides the guardrails for a single benchmark, normally
+// sourced from that Benchmark's own CR spec.
+func (c *ResultCollector) SetGuardrails(benchmarkName string, cfg GuardrailConfig) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	if c.guardrails == nil {
+		c.guardrails = make(map[string]GuardrailConfig)
+	}
+	c.guardrails[benchmarkName] = cfg
+}
+
+func (c *ResultCollector) guardrailFor(benchmarkName string) GuardrailConfig {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
+	if cfg, ok := c.guardrails[benchmarkName]; ok {
+		return cfg
+	}
+	return c.defaultGuardrails
+}
+
+func labelSignature(labels prometheus.Labels) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// labelValuesWithinLimit reports whether every value in labels is within
+// cfg's MaxLabelValueLength, dropping (and counting in
+// cpe_result_dropped_total) benchmarkName's sample otherwise. It is the
+// length-only half of guardrailsPass, split out so observeDistributions can
+// apply the same limit at cache-write time without also touching
+// guardrailsPass's per-scrape cardinality bookkeeping.
+func (c *ResultCollector) labelValuesWithinLimit(benchmarkName string, labels prometheus.Labels, cfg GuardrailConfig) bool {
+	for _, v := range labels {
+		if len(v) > cfg.maxLabelValueLength() {
+			c.droppedTotal.WithLabelValues(benchmarkName, "label_value_too_long").Inc()
+			return false
+		}
+	}
+	return true
+}
+
+// guardrailsPass reports whether labels may be recorded for benchmarkName,
+// dropping (and counting in cpe_result_dropped_total) samples whose label
+// values are too long or that would add a new series beyond
+// MaxSeriesPerBenchmark. A label combination already seen this scrape never
+// counts as "new"; the min/max/avg gauges of one ValuesWithLabels entry
+// differ in their attrbs suffix and so count as three series.
+func (c *ResultCollector) guardrailsPass(benchmarkName string, labels prometheus.Labels) bool {
+	cfg := c.guardrailFor(benchmarkName)
+
+	if !c.labelValuesWithinLimit(benchmarkName, labels, cfg) {
+		return false
+	}
+
+	sig := labelSignature(labels)
+	if c.seriesSeen == nil {
+		c.seriesSeen = make(map[string]map[string]struct{})
+	}
+	seen := c.seriesSeen[benchmarkName]
+	if seen == nil {
+		seen = make(map[string]struct{})
+		c.seriesSeen[benchmarkName] = seen
+	}
+	if _, ok := seen[sig]; ok {
+		return true
+	}
+	if len(seen) >= cfg.maxSeries() {
+		c.droppedTotal.WithLabelValues(benchmarkName, "cardinality").Inc()
+		return false
+	}
+	seen[sig] = struct{}{}
+	c.seriesCount.WithLabelValues(benchmarkName).Set(float64(len(seen)))
+	return true
+}
+
+// resetGuardrailState clears the per-scrape series-seen tracking and the
+// seriesCount gauge. It must be called at the start of every Collect (and
+// every PushItem), alongside resultVectors.Reset(), so that a benchmark
+// that stops reporting - e.g. its Benchmark CR was deleted and
+// RemoveBenchmark evicted its cache entries - doesn't leave
+// cpe_result_series_count stuck at its last observed value forever.
+func (c *ResultCollector) resetGuardrailState() {
+	c.seriesSeen = make(map[string]map[string]struct{})
+	c.seriesCount.Reset()
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2022- IBM Inc. All rights reserved
+ * SPDX-License-Identifier: Apache2.0
+ */
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestGuardrailsPassDropsOversizedLabelValue(t *testing.T) {
+	c := newResultCollector(nil, discardLogger())
+	c.SetDefaultGuardrails(GuardrailConfig{MaxLabelValueLength: 4})
+
+	if c.guardrailsPass("fio", prometheus.Labels{"attrbs": "way too long"}) {
+		t.Fatal("expected a label value over MaxLabelValueLength to be dropped")
+	}
+	if got := testutilCounterValue(c.droppedTotal.WithLabelValues("fio", "label_value_too_long")); got != 1 {
+		t.Fatalf("expected cpe_result_dropped_total{reason=label_value_too_long} to be 1, got %v", got)
+	}
+}
+
+func TestGuardrailsPassEnforcesSeriesCap(t *testing.T) {
+	c := newResultCollector(nil, discardLogger())
+	c.SetDefaultGuardrails(GuardrailConfig{MaxSeriesPerBenchmark: 2})
+
+	if !c.guardrailsPass("fio", prometheus.Labels{"key": "a"}) {
+		t.Fatal("expected the first series to pass")
+	}
+	if !c.guardrailsPass("fio", prometheus.Labels{"key": "a"}) {
+		t.Fatal("expected an already-seen series to pass without counting against the cap")
+	}
+	if !c.guardrailsPass("fio", prometheus.Labels{"key": "b"}) {
+		t.Fatal("expected the second distinct series to pass")
+	}
+	if c.guardrailsPass("fio", prometheus.Labels{"key": "c"}) {
+		t.Fatal("expected a third distinct series to be dropped once MaxSeriesPerBenchmark is reached")
+	}
+	if got := testutilCounterValue(c.droppedTotal.WithLabelValues("fio", "cardinality")); got != 1 {
+		t.Fatalf("expected cpe_result_dropped_total{reason=cardinality} to be 1, got %v", got)
+	}
+	if got := testutilGaugeValue(c.seriesCount.WithLabelValues("fio")); got != 2 {
+		t.Fatalf("expected cpe_result_series_count{fio} to be 2, got %v", got)
+	}
+}
+
+func testutilCounterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	c.(prometheus.Metric).Write(&m)
+	return m.GetCounter().GetValue()
+}
+
+func testutilGaugeValue(g prometheus.Gauge) float64 {
+	var m dto.Metric
+	g.(prometheus.Metric).Write(&m)
+	return m.GetGauge().GetValue()
+}
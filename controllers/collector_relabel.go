@@ -0,0 +1,136 @@
+/*
+ * Copyright 2022- IBM Inc. All rights reserved
+ * SPDX-License-Identifier: Apache2.0
+ */
+
+package controllers
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/go-logr/logr"
+)
+
+// RelabelRule is one user-defined mapping rule, statsd_exporter-style.
+// In the cluster these are loaded from a ConfigMap referenced by the
+// Benchmark CR (or a cluster-scoped mapping CR) - see ParseRelabelConfigYAML,
+// which a reconciler reads and hands to the collector via SetRelabelConfig;
+// the collector itself has no opinion on where they came from.
+type RelabelRule struct {
+	// Match is a glob pattern (e.g. "fio/bw *") evaluated against both
+	// "benchmarkName/key" and the bare key, unless MatchIsRegex is set, in
+	// which case Match is a regexp evaluated the same way.
+	Match        string
+	MatchIsRegex bool
+	// TargetKey overrides the "key" label produced for a matching sample,
+	// e.g. "fio bw (MB/s)" -> "fio_bandwidth_bytes_per_second". Empty keeps
+	// the legacy relabelKey output.
+	TargetKey string
+	// PromoteLabels lists label-map keys (ValueWithLabels.Labels /
+	// ValuesWithLabels.Labels) that become first-class Prometheus labels
+	// instead of being folded into the opaque attrbs label.
+	PromoteLabels []string
+	// ValueMultiplier converts the raw value's unit, e.g. MB/s -> B/s. Zero
+	// is treated as 1 (no conversion).
+	ValueMultiplier float64
+
+	compiled *regexp.Regexp
+}
+
+// matches assumes compiled is already populated for regex rules - SetConfig
+// is the only place that compiles a rule, so an invalid regex never reaches
+// this hot path.
+func (r *RelabelRule) matches(candidate string) bool {
+	if r.MatchIsRegex {
+		if r.compiled == nil {
+			return false
+		}
+		return r.compiled.MatchString(candidate)
+	}
+	ok, err := filepath.Match(r.Match, candidate)
+	return err == nil && ok
+}
+
+// RelabelConfig is the full ordered set of mapping rules for a collector.
+// Rules are evaluated in order and the first match wins, mirroring
+// statsd_exporter's mapping config semantics.
+type RelabelConfig struct {
+	Rules []RelabelRule
+}
+
+// relabelResolver matches (benchmarkName, rawKey) pairs against a
+// RelabelConfig and caches the result, since matching happens on every
+// sample of every scrape.
+type relabelResolver struct {
+	mu      sync.RWMutex
+	log     logr.Logger
+	config  RelabelConfig
+	cache   map[string]*RelabelRule
+	visited map[string]bool
+}
+
+func newRelabelResolver(logger logr.Logger) *relabelResolver {
+	return &relabelResolver{
+		log:     logger,
+		cache:   make(map[string]*RelabelRule),
+		visited: make(map[string]bool),
+	}
+}
+
+// SetConfig replaces the active mapping rules and drops the match cache.
+// Regex rules are compiled here, eagerly, rather than lazily on the first
+// scrape that needs them: an invalid regex (e.g. a typo in the ConfigMap
+// backing this config) is logged and the rule dropped, instead of panicking
+// inside Collect's call chain and crashing the whole operator process.
+func (rr *relabelResolver) SetConfig(cfg RelabelConfig) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	valid := make([]RelabelRule, 0, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		if rule.MatchIsRegex {
+			compiled, err := regexp.Compile(rule.Match)
+			if err != nil {
+				rr.log.Info(fmt.Sprintf("Dropping relabel rule with invalid regex %q: %v", rule.Match, err))
+				continue
+			}
+			rule.compiled = compiled
+		}
+		valid = append(valid, rule)
+	}
+	rr.config = RelabelConfig{Rules: valid}
+	rr.cache = make(map[string]*RelabelRule)
+	rr.visited = make(map[string]bool)
+}
+
+// Resolve returns the first rule matching benchmarkName/rawKey, or nil.
+func (rr *relabelResolver) Resolve(benchmarkName, rawKey string) *RelabelRule {
+	id := benchmarkName + "/" + rawKey
+
+	rr.mu.RLock()
+	if rr.visited[id] {
+		rule := rr.cache[id]
+		rr.mu.RUnlock()
+		return rule
+	}
+	rr.mu.RUnlock()
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if rr.visited[id] {
+		return rr.cache[id]
+	}
+	var matched *RelabelRule
+	for i := range rr.config.Rules {
+		rule := &rr.config.Rules[i]
+		if rule.matches(id) || rule.matches(rawKey) {
+			matched = rule
+			break
+		}
+	}
+	rr.visited[id] = true
+	rr.cache[id] = matched
+	return matched
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2022- IBM Inc. All rights reserved
+ * SPDX-License-Identifier: Apache2.0
+ */
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+// discardLogger returns a no-op logr.Logger for tests that need one just to
+// satisfy a constructor.
+func discardLogger() logr.Logger {
+	return logr.Discard()
+}
+
+func TestRelabelResolverMatchPrecedence(t *testing.T) {
+	rr := newRelabelResolver(discardLogger())
+	rr.SetConfig(RelabelConfig{Rules: []RelabelRule{
+		{Match: "fio/bw *", TargetKey: "fio_bandwidth"},
+		{Match: "fio/*", TargetKey: "fio_generic"},
+		{Match: "^sysbench/.*lat.*$", MatchIsRegex: true, TargetKey: "sysbench_latency"},
+	}})
+
+	if rule := rr.Resolve("fio", "bw read"); rule == nil || rule.TargetKey != "fio_bandwidth" {
+		t.Fatalf("expected the first matching rule to win, got %+v", rule)
+	}
+	if rule := rr.Resolve("fio", "iops"); rule == nil || rule.TargetKey != "fio_generic" {
+		t.Fatalf("expected the fallback glob rule to match, got %+v", rule)
+	}
+	if rule := rr.Resolve("sysbench", "avg_latency"); rule == nil || rule.TargetKey != "sysbench_latency" {
+		t.Fatalf("expected the regex rule to match, got %+v", rule)
+	}
+	if rule := rr.Resolve("other", "key"); rule != nil {
+		t.Fatalf("expected no rule to match, got %+v", rule)
+	}
+}
+
+func TestRelabelResolverDropsInvalidRegex(t *testing.T) {
+	rr := newRelabelResolver(discardLogger())
+	rr.SetConfig(RelabelConfig{Rules: []RelabelRule{
+		{Match: "(", MatchIsRegex: true, TargetKey: "broken"},
+		{Match: "*", TargetKey: "fallback"},
+	}})
+
+	if rule := rr.Resolve("bench", "key"); rule == nil || rule.TargetKey != "fallback" {
+		t.Fatalf("expected the invalid regex rule to be dropped and the fallback to match, got %+v", rule)
+	}
+}
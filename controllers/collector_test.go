@@ -0,0 +1,49 @@
+/*
+ * Copyright 2022- IBM Inc. All rights reserved
+ * SPDX-License-Identifier: Apache2.0
+ */
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestClassifyResult(t *testing.T) {
+	cases := []struct {
+		name string
+		json string
+		want ResultType
+	}{
+		{"simple float", `1.5`, SimpleFloatType},
+		{"slice of numbers", `[1, 2, 3]`, SliceType},
+		{"empty slice", `[]`, InvalidType},
+		{"value with labels", `[{"Labels": {"stat": "avg"}, "Value": 1.5}]`, ValueWithLabelsType},
+		{"values with labels", `[{"Labels": {"percentile": "p95"}, "Values": [1, 2, 3]}]`, ValuesWithLabelsType},
+		{"object missing Labels", `[{"Value": 1.5}]`, InvalidType},
+		{"object missing Value and Values", `[{"Labels": {"stat": "avg"}}]`, InvalidType},
+		{"string", `"nope"`, InvalidType},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyResult(gjson.Parse(tc.json)); got != tc.want {
+				t.Fatalf("classifyResult(%s) = %v, want %v", tc.json, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGjsonLabels(t *testing.T) {
+	labels := gjsonLabels(gjson.Parse(`{"stat": "avg", "percentile": "p95"}`))
+	want := map[string]string{"stat": "avg", "percentile": "p95"}
+	if len(labels) != len(want) {
+		t.Fatalf("gjsonLabels returned %v, want %v", labels, want)
+	}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Fatalf("gjsonLabels()[%q] = %q, want %q", k, labels[k], v)
+		}
+	}
+}
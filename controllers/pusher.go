@@ -0,0 +1,333 @@
+/*
+ * Copyright 2022- IBM Inc. All rights reserved
+ * SPDX-License-Identifier: Apache2.0
+ */
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	cpev1 "github.com/IBM/cpe-operator/api/v1"
+	"github.com/go-logr/logr"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/tidwall/gjson"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PushAuth carries optional basic-auth credentials for the Pushgateway.
+type PushAuth struct {
+	Username string
+	Password string
+}
+
+// ResultPusher reuses ResultCollector's result-type switch logic
+// (updateGaugeVec for gauges, observeDistributions for histograms and
+// summaries) to populate the same gauge/histogram/summary vectors,
+// but instead of waiting to be scraped it actively pushes each benchmark
+// result to a Prometheus Pushgateway as soon as it is produced. This exists
+// because benchmark pods are short-lived: by the time a scrape would reach
+// the operator, the pod - and the scrape's usefulness as a timestamp - is
+// already gone.
+//
+// A controller wires this up via RegisterPusherFlags, which registers the
+// --push-gateway-url/--remote-write-url flags, and PusherFlags.NewPusher,
+// which builds the pusher from them once the flags are parsed.
+type ResultPusher struct {
+	*ResultCollector
+	url            string
+	auth           PushAuth
+	remoteWriteURL string
+
+	// interval is the minimum spacing between two pushes of the same
+	// benchmark/build/config/scenario/job/pod group, so a burst of
+	// reconciles doesn't hammer the gateway.
+	interval time.Duration
+
+	mu       sync.Mutex
+	lastPush map[string]time.Time
+
+	// pushMu serializes PushItem's reset/populate/push sequence.
+	// ResultCollector's vectors are shared across every PushItem call, so a
+	// multi-worker reconciler pushing two benchmarks concurrently would
+	// otherwise interleave one call's Reset() with another's still-filling
+	// vectors and push a mix of both benchmarks' series under one of their
+	// Pushgateway groupings.
+	pushMu sync.Mutex
+}
+
+// NewPusher creates a ResultPusher that pushes to the Pushgateway at url
+// using auth, rate-limited per group to at most once every interval.
+func NewPusher(client client.Client, logger logr.Logger, url string, auth PushAuth, interval time.Duration) *ResultPusher {
+	return &ResultPusher{
+		ResultCollector: newResultCollector(client, logger),
+		url:             url,
+		auth:            auth,
+		interval:        interval,
+		lastPush:        make(map[string]time.Time),
+	}
+}
+
+// WithRemoteWrite additionally ships every pushed sample via Prometheus
+// Remote Write to endpoint, stamped with the sample's own completion time
+// rather than the time of the push.
+func (p *ResultPusher) WithRemoteWrite(endpoint string) *ResultPusher {
+	p.remoteWriteURL = endpoint
+	return p
+}
+
+func pushGroupKey(benchmarkNamespace, benchmarkName, build, configID, scenarioID, jobName, podName string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s/%s/%s", benchmarkNamespace, benchmarkName, build, configID, scenarioID, jobName, podName)
+}
+
+func pushJobName(benchmarkNamespace, benchmarkName string) string {
+	return fmt.Sprintf("cpe_benchmark_%s_%s", benchmarkNamespace, benchmarkName)
+}
+
+func (p *ResultPusher) shouldPush(group string) bool {
+	if p.interval <= 0 {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if last, ok := p.lastPush[group]; ok && time.Since(last) < p.interval {
+		return false
+	}
+	p.lastPush[group] = time.Now()
+	return true
+}
+
+func (p *ResultPusher) pusherFor(benchmarkNamespace, benchmarkName, build, configID, scenarioID, jobName, podName string) *push.Pusher {
+	pusher := push.New(p.url, pushJobName(benchmarkNamespace, benchmarkName)).
+		Grouping("benchmark", benchmarkName).
+		Grouping("build", build).
+		Grouping("config", configID).
+		Grouping("scenario", scenarioID).
+		Grouping("job", jobName).
+		Grouping("pod", podName)
+	if p.auth.Username != "" {
+		pusher = pusher.BasicAuth(p.auth.Username, p.auth.Password)
+	}
+	return pusher
+}
+
+// PushItem parses one benchmark result item's JSON, pushes the resulting
+// series to the Pushgateway, and, if WithRemoteWrite was called, also ships
+// them via Remote Write stamped with completedAt.
+func (p *ResultPusher) PushItem(benchmarkNamespace, benchmarkName, build, configID, scenarioID, jobName, podName, result string, completedAt time.Time) error {
+	group := pushGroupKey(benchmarkNamespace, benchmarkName, build, configID, scenarioID, jobName, podName)
+	if !p.shouldPush(group) {
+		return nil
+	}
+
+	if !gjson.Valid(result) {
+		return fmt.Errorf("cannot parse values of %s/%s", benchmarkNamespace, benchmarkName)
+	}
+
+	p.pushMu.Lock()
+	defer p.pushMu.Unlock()
+
+	p.resultVectors.Reset()
+	p.resultHistograms.Reset()
+	p.resultSummaries.Reset()
+	p.resetGuardrailState()
+	// customGauges/customHistograms/customSummaries hold per benchmark/key
+	// vectors created on demand by gaugeVecFor/histogramVecFor/
+	// summaryVecFor for promoted-label rules or custom bucket/objective
+	// configs. Unlike ResultCollector.Collect, where these accumulate
+	// legitimately across scrapes of the same live benchmarks, each
+	// PushItem call is scoped to a single item - without resetting them
+	// here they'd keep every build/pod combination ever pushed and
+	// re-push all of it under this call's Pushgateway grouping.
+	for _, vec := range p.customGaugeSnapshot() {
+		vec.Reset()
+	}
+	for _, vec := range p.customHistogramSnapshot() {
+		vec.Reset()
+	}
+	for _, vec := range p.customSummarySnapshot() {
+		vec.Reset()
+	}
+	p.updateGaugeVec(benchmarkName, build, configID, scenarioID, jobName, podName, result)
+	// updateGaugeVec only sets gauges; since these vectors were just Reset
+	// above and are scoped to this single push, it's safe (and necessary) to
+	// also observe histograms/summaries here, unlike ResultCollector.Collect
+	// which must never re-observe an unchanged cache entry.
+	p.observeDistributions(benchmarkName, build, configID, scenarioID, jobName, podName, result)
+
+	pusher := p.pusherFor(benchmarkNamespace, benchmarkName, build, configID, scenarioID, jobName, podName).
+		Collector(p.resultVectors).
+		Collector(p.resultHistograms).
+		Collector(p.resultSummaries)
+	for _, vec := range p.customGaugeSnapshot() {
+		pusher = pusher.Collector(vec)
+	}
+	for _, vec := range p.customHistogramSnapshot() {
+		pusher = pusher.Collector(vec)
+	}
+	for _, vec := range p.customSummarySnapshot() {
+		pusher = pusher.Collector(vec)
+	}
+	if err := pusher.Push(); err != nil {
+		return fmt.Errorf("pushing %s/%s to %s: %w", benchmarkNamespace, benchmarkName, p.url, err)
+	}
+
+	if p.remoteWriteURL != "" {
+		if err := p.remoteWriteSnapshot(completedAt); err != nil {
+			p.Log.Info(fmt.Sprintf("Failed to remote-write result of %s/%s: %v", benchmarkNamespace, benchmarkName, err))
+		}
+	}
+	return nil
+}
+
+// PushBenchmark pushes every result item currently on benchmark.Status,
+// stamping all of them with completedAt for the Remote Write path. It is
+// intended to be called by the reconciler right after it writes a new
+// Benchmark status.
+func (p *ResultPusher) PushBenchmark(benchmark *cpev1.Benchmark, completedAt time.Time) {
+	for _, result := range benchmark.Status.Results {
+		for _, item := range result.Items {
+			err := p.PushItem(benchmark.Namespace, benchmark.Name, result.BuildID, result.ConfigurationID, result.IterationID, item.JobName, item.PodName, item.Result, completedAt)
+			if err != nil {
+				p.Log.Info(fmt.Sprintf("Failed to push result of %s/%s: %v", benchmark.Namespace, benchmark.Name, err))
+			}
+		}
+	}
+}
+
+// CleanupBenchmark removes every previously-pushed group for benchmark. It
+// is intended to be called by the reconciler's delete handler.
+func (p *ResultPusher) CleanupBenchmark(benchmark *cpev1.Benchmark) {
+	for _, result := range benchmark.Status.Results {
+		for _, item := range result.Items {
+			err := p.DeleteGroup(benchmark.Namespace, benchmark.Name, result.BuildID, result.ConfigurationID, result.IterationID, item.JobName, item.PodName)
+			if err != nil {
+				p.Log.Info(fmt.Sprintf("Failed to delete pushed group of %s/%s: %v", benchmark.Namespace, benchmark.Name, err))
+			}
+		}
+	}
+}
+
+// DeleteGroup removes a previously-pushed group from the Pushgateway. It is
+// intended to be called by the reconciler's delete handler so the gateway
+// doesn't keep serving samples for a benchmark that no longer exists.
+func (p *ResultPusher) DeleteGroup(benchmarkNamespace, benchmarkName, build, configID, scenarioID, jobName, podName string) error {
+	group := pushGroupKey(benchmarkNamespace, benchmarkName, build, configID, scenarioID, jobName, podName)
+	p.mu.Lock()
+	delete(p.lastPush, group)
+	p.mu.Unlock()
+	return p.pusherFor(benchmarkNamespace, benchmarkName, build, configID, scenarioID, jobName, podName).Delete()
+}
+
+// remoteWriteSnapshot gathers whatever is currently in this pusher's own
+// vectors (populated moments earlier by updateGaugeVec and
+// observeDistributions) and ships it via
+// Prometheus Remote Write, stamped with ts instead of time.Now so the
+// sample keeps the benchmark's own completion time. It registers the
+// custom per benchmark/key vectors (promoted-label gauges, custom-bucket
+// histograms, custom-objective summaries) alongside the default ones, so a
+// benchmark/key using those features isn't silently dropped from Remote
+// Write while still being pushed to the Pushgateway.
+func (p *ResultPusher) remoteWriteSnapshot(ts time.Time) error {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(p.resultVectors, p.resultHistograms, p.resultSummaries)
+	for _, vec := range p.customGaugeSnapshot() {
+		reg.MustRegister(vec)
+	}
+	for _, vec := range p.customHistogramSnapshot() {
+		reg.MustRegister(vec)
+	}
+	for _, vec := range p.customSummarySnapshot() {
+		reg.MustRegister(vec)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		return err
+	}
+
+	tsMillis := ts.UnixMilli()
+	var series []prompb.TimeSeries
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			series = append(series, dtoMetricToTimeSeries(family.GetName(), m, tsMillis)...)
+		}
+	}
+	if len(series) == 0 {
+		return nil
+	}
+	return sendRemoteWrite(p.remoteWriteURL, series)
+}
+
+// dtoMetricToTimeSeries converts one gathered metric into one or more
+// Remote Write time series. Histograms/summaries are reduced to their sum
+// and count, matching the simple gauge/min/max/avg style the rest of this
+// collector already exports, rather than reproducing every bucket.
+func dtoMetricToTimeSeries(name string, m *dto.Metric, tsMillis int64) []prompb.TimeSeries {
+	baseLabels := []prompb.Label{{Name: "__name__", Value: name}}
+	for _, lp := range m.GetLabel() {
+		baseLabels = append(baseLabels, prompb.Label{Name: lp.GetName(), Value: lp.GetValue()})
+	}
+
+	sample := func(extra *prompb.Label, value float64) prompb.TimeSeries {
+		labels := baseLabels
+		if extra != nil {
+			labels = append(append([]prompb.Label{}, baseLabels...), *extra)
+		}
+		return prompb.TimeSeries{
+			Labels:  labels,
+			Samples: []prompb.Sample{{Value: value, Timestamp: tsMillis}},
+		}
+	}
+
+	switch {
+	case m.Gauge != nil:
+		return []prompb.TimeSeries{sample(nil, m.GetGauge().GetValue())}
+	case m.Histogram != nil:
+		return []prompb.TimeSeries{
+			sample(&prompb.Label{Name: "stat", Value: "sum"}, m.GetHistogram().GetSampleSum()),
+			sample(&prompb.Label{Name: "stat", Value: "count"}, float64(m.GetHistogram().GetSampleCount())),
+		}
+	case m.Summary != nil:
+		return []prompb.TimeSeries{
+			sample(&prompb.Label{Name: "stat", Value: "sum"}, m.GetSummary().GetSampleSum()),
+			sample(&prompb.Label{Name: "stat", Value: "count"}, float64(m.GetSummary().GetSampleCount())),
+		}
+	default:
+		return nil
+	}
+}
+
+func sendRemoteWrite(endpoint string, series []prompb.TimeSeries) error {
+	data, err := proto.Marshal(&prompb.WriteRequest{Timeseries: series})
+	if err != nil {
+		return err
+	}
+	compressed := snappy.Encode(nil, data)
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("remote write to %s returned %s", endpoint, resp.Status)
+	}
+	return nil
+}
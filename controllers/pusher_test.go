@@ -0,0 +1,118 @@
+/*
+ * Copyright 2022- IBM Inc. All rights reserved
+ * SPDX-License-Identifier: Apache2.0
+ */
+
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// capturedPushes records the body of every push request by URL path, since
+// push.Pusher encodes each group's grouping labels into the request path,
+// and counts every request received.
+type capturedPushes struct {
+	mu       sync.Mutex
+	byURL    map[string]string
+	requests int
+}
+
+func (c *capturedPushes) record(path, body string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byURL == nil {
+		c.byURL = make(map[string]string)
+	}
+	c.byURL[path] = body
+	c.requests++
+}
+
+func (c *capturedPushes) requestCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests
+}
+
+func (c *capturedPushes) findByPathSubstring(substr string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, body := range c.byURL {
+		if strings.Contains(path, substr) {
+			return body, true
+		}
+	}
+	return "", false
+}
+
+func newCapturingPushgateway(t *testing.T) (*httptest.Server, *capturedPushes) {
+	t.Helper()
+	captured := &capturedPushes{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		captured.record(r.URL.Path, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	return server, captured
+}
+
+// TestPushItemResetsCustomVectorsBetweenGroups pushes the same promoted-label
+// key for two different build/pod groups through one ResultPusher and checks
+// that the second push's body carries only its own group's series. Before
+// customGauges/customHistograms/customSummaries were reset per PushItem,
+// the second push would still carry the first group's build label, since
+// both groups share the same customGauges entry keyed by "benchmark/key".
+func TestPushItemResetsCustomVectorsBetweenGroups(t *testing.T) {
+	server, captured := newCapturingPushgateway(t)
+	defer server.Close()
+
+	pusher := NewPusher(nil, discardLogger(), server.URL, PushAuth{}, 0)
+	pusher.SetRelabelConfig(RelabelConfig{Rules: []RelabelRule{
+		{Match: "lat", TargetKey: "lat_p95", PromoteLabels: []string{"percentile"}},
+	}})
+
+	result := `{"lat": [{"Labels": {"percentile": "p95"}, "Value": 10}]}`
+	if err := pusher.PushItem("ns", "fio", "buildA", "cfg", "scenario", "job1", "podX", result, time.Now()); err != nil {
+		t.Fatalf("first PushItem: %v", err)
+	}
+	if err := pusher.PushItem("ns", "fio", "buildB", "cfg", "scenario", "job1", "podY", result, time.Now()); err != nil {
+		t.Fatalf("second PushItem: %v", err)
+	}
+
+	body, ok := captured.findByPathSubstring("buildB")
+	if !ok {
+		t.Fatal("expected a push request for the buildB group")
+	}
+	if strings.Contains(body, `build="buildA"`) {
+		t.Fatalf("expected buildB's push to not carry buildA's series, got body:\n%s", body)
+	}
+	if !strings.Contains(body, `build="buildB"`) {
+		t.Fatalf("expected buildB's push to carry its own series, got body:\n%s", body)
+	}
+}
+
+// TestPushItemRateLimitsPerGroup checks that two pushes of the same group
+// within interval are collapsed into one request.
+func TestPushItemRateLimitsPerGroup(t *testing.T) {
+	server, captured := newCapturingPushgateway(t)
+	defer server.Close()
+
+	pusher := NewPusher(nil, discardLogger(), server.URL, PushAuth{}, time.Hour)
+	result := `10`
+	if err := pusher.PushItem("ns", "fio", "buildA", "cfg", "scenario", "job1", "podX", result, time.Now()); err != nil {
+		t.Fatalf("first PushItem: %v", err)
+	}
+	before := captured.requestCount()
+	if err := pusher.PushItem("ns", "fio", "buildA", "cfg", "scenario", "job1", "podX", result, time.Now()); err != nil {
+		t.Fatalf("second PushItem: %v", err)
+	}
+	if after := captured.requestCount(); after != before {
+		t.Fatalf("expected the rate-limited second push to be skipped, captured %d requests before and %d after", before, after)
+	}
+}